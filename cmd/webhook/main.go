@@ -0,0 +1,67 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Admission webhook server for kubeless Function and Trigger CRDs.
+package main
+
+import (
+	"flag"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubeless/kubeless/pkg/langruntime"
+	"github.com/kubeless/kubeless/pkg/triggers"
+	"github.com/kubeless/kubeless/pkg/utils"
+	"github.com/kubeless/kubeless/pkg/webhook"
+)
+
+func main() {
+	listenAddr := flag.String("listen-addr", ":8443", "address the webhook HTTPS server listens on")
+	certFile := flag.String("tls-cert-file", "/etc/webhook/certs/cert.pem", "path to the TLS certificate")
+	keyFile := flag.String("tls-key-file", "/etc/webhook/certs/key.pem", "path to the TLS private key")
+	flag.Parse()
+
+	kubeCli, err := utils.GetClient()
+	if err != nil {
+		logrus.Fatalf("Unable to create Kubernetes client: %v", err)
+	}
+
+	configLocation := utils.GetConfigLocation()
+	config, err := kubeCli.CoreV1().ConfigMaps(configLocation["namespace"]).Get(configLocation["name"], v1.GetOptions{})
+	if err != nil {
+		logrus.Fatalf("Unable to read the configmap: %v", err)
+	}
+
+	lr := langruntime.New(config)
+	lr.ReadConfigMap()
+
+	// Registers the same TriggerSources the controller runs, purely so
+	// validateTrigger can call source.Validate - this process never
+	// reconciles anything.
+	triggers.RegisterBuiltins(kubeCli, triggers.KafkaConfig{})
+
+	server := webhook.New(webhook.Config{
+		ListenAddr:  *listenAddr,
+		CertFile:    *certFile,
+		KeyFile:     *keyFile,
+		LangRuntime: lr,
+	})
+
+	if err := server.Run(); err != nil {
+		logrus.Fatalf("Webhook server exited: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionStatus mirrors metav1.ConditionStatus ("True"/"False"/"Unknown").
+// It's a local enum rather than a reuse of metav1.Condition because this
+// repo's vendored apimachinery predates that type.
+type ConditionStatus string
+
+const (
+	// ConditionTrue means the condition is currently satisfied
+	ConditionTrue ConditionStatus = "True"
+	// ConditionFalse means the condition is not currently satisfied
+	ConditionFalse ConditionStatus = "False"
+	// ConditionUnknown means the condition could not be evaluated
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// ConditionType identifies the aspect of reconciliation a Condition reports on
+type ConditionType string
+
+const (
+	// ConfigMapReady is set once the function's ConfigMap has been created/updated
+	ConfigMapReady ConditionType = "ConfigMapReady"
+	// ServiceReady is set once the function's Service has been created/updated
+	ServiceReady ConditionType = "ServiceReady"
+	// DeploymentAvailable is set once the function's Deployment has been created/updated
+	DeploymentAvailable ConditionType = "DeploymentAvailable"
+	// CronJobScheduled is set once a Scheduled trigger's CronJob has been created/updated
+	CronJobScheduled ConditionType = "CronJobScheduled"
+	// AutoscalerReady is set once the function's HorizontalPodAutoscaler has been created/updated
+	AutoscalerReady ConditionType = "AutoscalerReady"
+	// TriggerReady is set once an HTTP or PubSub trigger's backing objects have been created/updated
+	TriggerReady ConditionType = "TriggerReady"
+)
+
+// Condition is patterned after metav1.Condition, kept local so this package
+// doesn't need a newer client-go than the rest of the repo.
+type Condition struct {
+	Type               ConditionType   `json:"type"`
+	Status             ConditionStatus `json:"status"`
+	Reason             string          `json:"reason,omitempty"`
+	Message            string          `json:"message,omitempty"`
+	LastTransitionTime metav1.Time     `json:"lastTransitionTime,omitempty"`
+}
+
+// FunctionStatus is the status subresource of a Function
+type FunctionStatus struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// TriggerStatus is the status subresource of a Trigger
+type TriggerStatus struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// UpsertCondition returns conditions with condition inserted, replacing any
+// existing entry of the same Type so each ConditionType appears at most
+// once. Shared by Function and Trigger status updates so both keep the same
+// dedup behavior.
+func UpsertCondition(conditions []Condition, condition Condition) []Condition {
+	for i := range conditions {
+		if conditions[i].Type == condition.Type {
+			conditions[i] = condition
+			return conditions
+		}
+	}
+	return append(conditions, condition)
+}
@@ -0,0 +1,51 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import "testing"
+
+func TestUpsertConditionAppendsNewType(t *testing.T) {
+	conditions := []Condition{{Type: ConfigMapReady, Status: ConditionTrue}}
+
+	got := UpsertCondition(conditions, Condition{Type: ServiceReady, Status: ConditionTrue})
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 conditions, got %d", len(got))
+	}
+	if got[1].Type != ServiceReady {
+		t.Errorf("expected appended condition to be %q, got %q", ServiceReady, got[1].Type)
+	}
+}
+
+func TestUpsertConditionReplacesExistingType(t *testing.T) {
+	conditions := []Condition{
+		{Type: ConfigMapReady, Status: ConditionTrue, Reason: "ConfigMapReady"},
+		{Type: ServiceReady, Status: ConditionFalse, Reason: "ServiceFailed"},
+	}
+
+	got := UpsertCondition(conditions, Condition{Type: ServiceReady, Status: ConditionTrue, Reason: "ServiceReady"})
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 conditions, got %d", len(got))
+	}
+	if got[1].Status != ConditionTrue || got[1].Reason != "ServiceReady" {
+		t.Errorf("expected ServiceReady condition to be replaced, got %+v", got[1])
+	}
+	if got[0].Type != ConfigMapReady {
+		t.Errorf("expected unrelated condition to be left alone, got %+v", got[0])
+	}
+}
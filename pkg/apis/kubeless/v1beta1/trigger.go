@@ -28,16 +28,17 @@ import (
 type Trigger struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata"`
-	Spec              TriggerSpec `json:"spec"`
+	Spec              TriggerSpec   `json:"spec"`
+	Status            TriggerStatus `json:"status,omitempty"`
 }
 
 // TriggerSpec contains Trigger specification
 type TriggerSpec struct {
-	Type                    string                          `json:"type"`                  // Trigger type
-	Topic                   string                          `json:"topic"`                 // Trigger topic (for PubSub type)
-	Schedule                string                          `json:"schedule"`              // Scheduled time (for Schedule type)
-	ServiceSpec             v1.ServiceSpec                  `json:"service"`	           // Specification on how service to be exposed in case HTTP trigger type
-	FunctionName            string                          `json:"function-name"`         // Function name associated with the trigger
+	Type             string                `json:"type"`               // Trigger type
+	Topic            string                `json:"topic"`              // Trigger topic (for PubSub type)
+	Schedule         string                `json:"schedule"`           // Scheduled time (for Schedule type)
+	ServiceSpec      v1.ServiceSpec        `json:"service"`            // Specification on how service to be exposed in case HTTP trigger type
+	FunctionSelector *metav1.LabelSelector `json:"function-selector"`  // Label selector matching the Functions this trigger fans out to
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
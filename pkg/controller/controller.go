@@ -18,6 +18,8 @@ package controller
 
 import (
 	"fmt"
+	"os"
+	"sync/atomic"
 	"time"
 
 	monitoringv1alpha1 "github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
@@ -31,6 +33,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/ghodss/yaml"
@@ -45,25 +49,70 @@ const (
 	maxRetries = 5
 	funcKind   = "Function"
 	funcAPI    = "kubeless.io"
+
+	defaultConcurrentWorkers = 1
+	leaseDuration            = 15 * time.Second
+	renewDeadline            = 10 * time.Second
+	retryPeriod              = 2 * time.Second
+
+	// defaultGCSafetyNetInterval is how often runGCSafetyNet re-sweeps the
+	// owned-object indexers, to catch anything missed while this replica
+	// wasn't the leader or was down when the owning Function was deleted.
+	defaultGCSafetyNetInterval = 5 * time.Minute
 )
 
 // Controller object
 type Controller struct {
-	logger         *logrus.Entry
-	clientset      kubernetes.Interface
-	kubelessclient versioned.Interface
-	smclient       *monitoringv1alpha1.MonitoringV1alpha1Client
-	Functions      map[string]*kubelessApi.Function
-	queue          workqueue.RateLimitingInterface
-	informer       cache.SharedIndexInformer
-	config         *corev1.ConfigMap
-	langRuntime    *langruntime.Langruntimes
+	logger              *logrus.Entry
+	clientset           kubernetes.Interface
+	kubelessclient      versioned.Interface
+	smclient            *monitoringv1alpha1.MonitoringV1alpha1Client
+	Functions           map[string]*kubelessApi.Function
+	queue               workqueue.RateLimitingInterface
+	informer            cache.SharedIndexInformer
+	config              *corev1.ConfigMap
+	langRuntime         *langruntime.Langruntimes
+	concurrentWorkers   int
+	gcSafetyNetInterval time.Duration
+	leaseLockName       string
+	leaseLockNamespace  string
+	identity            string
+	leading             int32 // accessed atomically; only the elected leader drains the queue
+
+	// Owned-object informers backing the event-driven GC in gc.go. Filtered
+	// server-side by functionLabel and indexed by owner UID. CronJobs are
+	// GC'd by TriggerController instead, since chunk0-1 moved their
+	// ownership from the Function to the Trigger.
+	serviceInformer    cache.SharedIndexInformer
+	deploymentInformer cache.SharedIndexInformer
+	configMapInformer  cache.SharedIndexInformer
+	hpaInformer        cache.SharedIndexInformer
+}
+
+// isLeading reports whether this replica currently holds the controller
+// lease. Only the leader's GC event handlers enqueue keys, since only the
+// leader's workers drain the queue.
+func (c *Controller) isLeading() bool {
+	return atomic.LoadInt32(&c.leading) == 1
 }
 
 // Config contains k8s client of a controller
 type Config struct {
 	KubeCli        kubernetes.Interface
 	FunctionClient versioned.Interface
+
+	// ConcurrentWorkers is the number of runWorker goroutines started by
+	// the leader. Defaults to 1 if unset.
+	ConcurrentWorkers int
+	// GCSafetyNetInterval is how often the leader re-sweeps the owned-object
+	// indexers for orphans, on top of the event-driven GC in gc.go. Defaults
+	// to 5 minutes if unset.
+	GCSafetyNetInterval time.Duration
+	// LeaseLockName/LeaseLockNamespace identify the lock object used for
+	// leader election. LeaseLockNamespace defaults to the controller's own
+	// namespace (the same namespace resolved via utils.GetConfigLocation).
+	LeaseLockName      string
+	LeaseLockNamespace string
 }
 
 // New initializes a controller object
@@ -105,19 +154,54 @@ func New(cfg Config, smclient *monitoringv1alpha1.MonitoringV1alpha1Client) *Con
 	var lr = langruntime.New(config)
 	lr.ReadConfigMap()
 
-	return &Controller{
-		logger:         logrus.WithField("pkg", "controller"),
-		clientset:      cfg.KubeCli,
-		smclient:       smclient,
-		kubelessclient: cfg.FunctionClient,
-		informer:       informer,
-		queue:          queue,
-		config:         config,
-		langRuntime:    lr,
+	concurrentWorkers := cfg.ConcurrentWorkers
+	if concurrentWorkers <= 0 {
+		concurrentWorkers = defaultConcurrentWorkers
+	}
+
+	gcSafetyNetInterval := cfg.GCSafetyNetInterval
+	if gcSafetyNetInterval <= 0 {
+		gcSafetyNetInterval = defaultGCSafetyNetInterval
+	}
+
+	leaseLockNamespace := cfg.LeaseLockNamespace
+	if leaseLockNamespace == "" {
+		leaseLockNamespace = controllerNamespace
+	}
+	leaseLockName := cfg.LeaseLockName
+	if leaseLockName == "" {
+		leaseLockName = "kubeless-controller"
 	}
+
+	identity, err := os.Hostname()
+	if err != nil {
+		identity = "kubeless-controller-unknown"
+	}
+
+	c := &Controller{
+		logger:              logrus.WithField("pkg", "controller"),
+		clientset:           cfg.KubeCli,
+		smclient:            smclient,
+		kubelessclient:      cfg.FunctionClient,
+		informer:            informer,
+		queue:               queue,
+		config:              config,
+		langRuntime:         lr,
+		concurrentWorkers:   concurrentWorkers,
+		gcSafetyNetInterval: gcSafetyNetInterval,
+		leaseLockName:       leaseLockName,
+		leaseLockNamespace:  leaseLockNamespace,
+		identity:            identity,
+	}
+	c.setupGCInformers()
+	return c
 }
 
-// Run starts the kubeless controller
+// Run starts the kubeless controller. The Function informer and the
+// owned-object GC informers are kept warm on every replica, but only the
+// elected leader drains the workqueue - running more than one replica
+// without this would race on the Deployments/Services/ConfigMaps/HPAs the
+// workers mutate.
 func (c *Controller) Run(stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer c.queue.ShutDown()
@@ -125,18 +209,59 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 	c.logger.Info("Starting kubeless controller")
 
 	go c.informer.Run(stopCh)
+	for _, informer := range c.gcInformers() {
+		go informer.Run(stopCh)
+	}
 
-	if !cache.WaitForCacheSync(stopCh, c.HasSynced) {
+	synced := append([]cache.InformerSynced{c.HasSynced}, c.gcInformersSynced()...)
+	if !cache.WaitForCacheSync(stopCh, synced...) {
 		utilruntime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
 		return
 	}
 
 	c.logger.Info("Kubeless controller synced and ready")
 
-	// run one round of GC at startup to detect orphaned objects from the last time
-	c.garbageCollect()
-
-	wait.Until(c.runWorker, time.Second, stopCh)
+	lock := &resourcelock.ConfigMapLock{
+		ConfigMapMeta: metav1.ObjectMeta{
+			Name:      c.leaseLockName,
+			Namespace: c.leaseLockNamespace,
+		},
+		Client:     c.clientset.CoreV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: c.identity},
+	}
+
+	leaderelection.RunOrDie(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				atomic.StoreInt32(&c.leading, 1)
+				c.logger.Infof("%s became leader, starting %d worker(s)", c.identity, c.concurrentWorkers)
+
+				// Sweep once right away to catch anything that was orphaned
+				// while no replica was leading, then keep sweeping as a
+				// safety net for events the informers above might miss.
+				c.runGCSafetyNet()
+				go wait.Until(c.runGCSafetyNet, c.gcSafetyNetInterval, stop)
+
+				for i := 0; i < c.concurrentWorkers; i++ {
+					go wait.Until(c.runWorker, time.Second, stop)
+				}
+				<-stop
+			},
+			OnStoppedLeading: func() {
+				atomic.StoreInt32(&c.leading, 0)
+				c.logger.Infof("%s is no longer the leader", c.identity)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != c.identity {
+					c.logger.Infof("New leader elected: %s", identity)
+				}
+			},
+		},
+	})
 }
 
 // HasSynced is required for the cache.Controller interface.
@@ -227,30 +352,29 @@ func (c *Controller) ensureK8sResources(funcObj *kubelessApi.Function) error {
 
 	err = utils.EnsureFuncConfigMap(c.clientset, funcObj, or, c.langRuntime)
 	if err != nil {
+		c.updateFunctionStatus(funcObj, kubelessApi.ConfigMapReady, kubelessApi.ConditionFalse, "ConfigMapFailed", err.Error())
 		return err
 	}
+	c.updateFunctionStatus(funcObj, kubelessApi.ConfigMapReady, kubelessApi.ConditionTrue, "ConfigMapReady", "")
 
 	err = utils.EnsureFuncService(c.clientset, funcObj, or)
 	if err != nil {
+		c.updateFunctionStatus(funcObj, kubelessApi.ServiceReady, kubelessApi.ConditionFalse, "ServiceFailed", err.Error())
 		return err
 	}
+	c.updateFunctionStatus(funcObj, kubelessApi.ServiceReady, kubelessApi.ConditionTrue, "ServiceReady", "")
 
 	err = utils.EnsureFuncDeployment(c.clientset, funcObj, or, c.langRuntime)
 	if err != nil {
+		c.updateFunctionStatus(funcObj, kubelessApi.DeploymentAvailable, kubelessApi.ConditionFalse, "DeploymentFailed", err.Error())
 		return err
 	}
+	c.updateFunctionStatus(funcObj, kubelessApi.DeploymentAvailable, kubelessApi.ConditionTrue, "DeploymentAvailable", "")
 
-	if funcObj.Spec.Type == "Scheduled" {
-		restIface := c.clientset.BatchV2alpha1().RESTClient()
-		groupVersion, err := c.getResouceGroupVersion("cronjobs")
-		if err != nil {
-			return err
-		}
-		err = utils.EnsureFuncCronJob(restIface, funcObj, or, groupVersion)
-		if err != nil {
-			return err
-		}
-	}
+	// Scheduled functions used to get their CronJob created right here, but
+	// that is now owned by the Scheduled trigger reconciler in
+	// TriggerController so a function can be driven by more than one
+	// trigger without the Function controller needing to know about it.
 
 	if funcObj.Spec.HorizontalPodAutoscaler.Name != "" && funcObj.Spec.HorizontalPodAutoscaler.Spec.ScaleTargetRef.Name != "" {
 		funcObj.Spec.HorizontalPodAutoscaler.OwnerReferences = or
@@ -263,8 +387,10 @@ func (c *Controller) ensureK8sResources(funcObj *kubelessApi.Function) error {
 		}
 		err = utils.CreateAutoscale(c.clientset, funcObj.Spec.HorizontalPodAutoscaler)
 		if err != nil {
+			c.updateFunctionStatus(funcObj, kubelessApi.AutoscalerReady, kubelessApi.ConditionFalse, "AutoscalerFailed", err.Error())
 			return err
 		}
+		c.updateFunctionStatus(funcObj, kubelessApi.AutoscalerReady, kubelessApi.ConditionTrue, "AutoscalerReady", "")
 	} else {
 		// HorizontalPodAutoscaler doesn't exists, try to delete if it already existed
 		err = c.deleteAutoscale(funcObj.ObjectMeta.Namespace, funcObj.ObjectMeta.Name)
@@ -275,6 +401,24 @@ func (c *Controller) ensureK8sResources(funcObj *kubelessApi.Function) error {
 	return nil
 }
 
+// updateFunctionStatus records a condition on the function's status
+// subresource, leaving Spec untouched so the two don't race on write.
+func (c *Controller) updateFunctionStatus(funcObj *kubelessApi.Function, condType kubelessApi.ConditionType, status kubelessApi.ConditionStatus, reason, message string) {
+	condition := kubelessApi.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+	funcObj.Status.Conditions = kubelessApi.UpsertCondition(funcObj.Status.Conditions, condition)
+	funcObj.Status.ObservedGeneration = funcObj.ObjectMeta.Generation
+
+	if _, err := c.kubelessclient.KubelessV1beta1().Functions(funcObj.ObjectMeta.Namespace).UpdateStatus(funcObj); err != nil {
+		c.logger.Errorf("Unable to update status of function %s: %v", funcObj.ObjectMeta.Name, err)
+	}
+}
+
 func (c *Controller) deleteAutoscale(ns, name string) error {
 	if c.smclient != nil {
 		// Delete Service monitor if the client is available
@@ -291,20 +435,13 @@ func (c *Controller) deleteAutoscale(ns, name string) error {
 	return nil
 }
 
-// deleteK8sResources removes k8s objects of the function
+// deleteK8sResources removes k8s objects of the function. CronJobs owned by
+// a Scheduled trigger are cleaned up by TriggerController when the Trigger
+// itself is deleted, not here.
 func (c *Controller) deleteK8sResources(ns, name string) error {
-	//check if func is scheduled or not
-	_, err := c.clientset.BatchV2alpha1().CronJobs(ns).Get(fmt.Sprintf("trigger-%s", name), metav1.GetOptions{})
-	if err == nil {
-		err = c.clientset.BatchV2alpha1().CronJobs(ns).Delete(fmt.Sprintf("trigger-%s", name), &metav1.DeleteOptions{})
-		if err != nil && !k8sErrors.IsNotFound(err) {
-			return err
-		}
-	}
-
 	// delete deployment
 	deletePolicy := metav1.DeletePropagationBackground
-	err = c.clientset.Extensions().Deployments(ns).Delete(name, &metav1.DeleteOptions{PropagationPolicy: &deletePolicy})
+	err := c.clientset.Extensions().Deployments(ns).Delete(name, &metav1.DeleteOptions{PropagationPolicy: &deletePolicy})
 	if err != nil && !k8sErrors.IsNotFound(err) {
 		return err
 	}
@@ -364,85 +501,7 @@ func (c *Controller) processItem(key string) error {
 	return nil
 }
 
-func (c *Controller) garbageCollect() error {
-	err := c.collectServices()
-	if err != nil {
-		return err
-	}
-	err = c.collectDeployment()
-	if err != nil {
-		return err
-	}
-	err = c.collectConfigMap()
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (c *Controller) collectServices() error {
-	srvs, err := c.clientset.CoreV1().Services(corev1.NamespaceAll).List(metav1.ListOptions{})
-	if err != nil {
-		return err
-	}
-
-	for _, srv := range srvs.Items {
-		if len(srv.OwnerReferences) == 0 {
-			continue
-		}
-		// Include the derived key from existing svc owner reference to the workqueue
-		// This will make sure the controller can detect the non-existing function and
-		// react to delete its belonging objects
-		// Assumption: a service has ownerref Kind = "Function" and APIVersion = "k8s.io" is assumed
-		// to be created by kubeless controller
-		if (srv.OwnerReferences[0].Kind == funcKind) && (srv.OwnerReferences[0].APIVersion == funcAPI) {
-			//service and its function are deployed in the same namespace
-			key := fmt.Sprintf("%s/%s", srv.Namespace, srv.OwnerReferences[0].Name)
-			c.queue.Add(key)
-		}
-	}
-
-	return nil
-}
-
-func (c *Controller) collectDeployment() error {
-	ds, err := c.clientset.AppsV1beta1().Deployments(corev1.NamespaceAll).List(metav1.ListOptions{})
-	if err != nil {
-		return err
-	}
-
-	for _, d := range ds.Items {
-		if len(d.OwnerReferences) == 0 {
-			continue
-		}
-		// Assumption: a deployment has ownerref Kind = "Function" and APIVersion = "k8s.io" is assumed
-		// to be created by kubeless controller
-		if (d.OwnerReferences[0].Kind == funcKind) && (d.OwnerReferences[0].APIVersion == funcAPI) {
-			key := fmt.Sprintf("%s/%s", d.Namespace, d.OwnerReferences[0].Name)
-			c.queue.Add(key)
-		}
-	}
-
-	return nil
-}
-
-func (c *Controller) collectConfigMap() error {
-	cm, err := c.clientset.CoreV1().ConfigMaps(corev1.NamespaceAll).List(metav1.ListOptions{})
-	if err != nil {
-		return err
-	}
-
-	for _, m := range cm.Items {
-		if len(m.OwnerReferences) == 0 {
-			continue
-		}
-		// Assumption: a configmap has ownerref Kind = "Function" and APIVersion = "k8s.io" is assumed
-		// to be created by kubeless controller
-		if (m.OwnerReferences[0].Kind == funcKind) && (m.OwnerReferences[0].APIVersion == funcAPI) {
-			key := fmt.Sprintf("%s/%s", m.Namespace, m.OwnerReferences[0].Name)
-			c.queue.Add(key)
-		}
-	}
-
-	return nil
-}
+// Garbage collection is implemented in gc.go: owned-object informers translate
+// Add/Update/Delete events into enqueues reactively, and
+// runGCSafetyNet provides a periodic incremental sweep using those same
+// informers' indexers instead of a fresh List.
@@ -0,0 +1,172 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	autoscalingv2beta1informers "k8s.io/client-go/informers/autoscaling/v2beta1"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	extensionsv1beta1informers "k8s.io/client-go/informers/extensions/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// functionLabel is set on every object the controller owns (Service,
+	// Deployment, ConfigMap, HPA, ServiceMonitor), so GC can list candidates
+	// with a single label selector instead of scanning everything. CronJobs
+	// are owned by their Trigger, not the Function, since chunk0-1 - see
+	// TriggerController.runGCSafetyNet for their GC.
+	functionLabel = "kubeless.io/function"
+
+	// ownerUIDIndex indexes owned objects by the UID of their first
+	// OwnerReference. The event handlers below derive the parent Function
+	// key from the object's own OwnerReference and do not query this index.
+	ownerUIDIndex = "ownerUID"
+)
+
+func byFunctionLabel(options *metav1.ListOptions) {
+	options.LabelSelector = functionLabel
+}
+
+func ownerUIDIndexFunc(obj interface{}) ([]string, error) {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.GetOwnerReferences()) == 0 {
+		return []string{}, nil
+	}
+	return []string{string(o.GetOwnerReferences()[0].UID)}, nil
+}
+
+// setupGCInformers builds the SharedIndexInformers used to GC Services,
+// Deployments, ConfigMaps and HPAs. Each is filtered server-side by
+// functionLabel and indexed by owner UID; an event on an owned object
+// translates into an enqueue of its parent Function key, read from the
+// object's own OwnerReference, without a fresh List.
+func (c *Controller) setupGCInformers() {
+	indexers := cache.Indexers{ownerUIDIndex: ownerUIDIndexFunc}
+
+	c.serviceInformer = corev1informers.NewFilteredServiceInformer(c.clientset, corev1.NamespaceAll, 0, indexers, byFunctionLabel)
+	c.deploymentInformer = extensionsv1beta1informers.NewFilteredDeploymentInformer(c.clientset, corev1.NamespaceAll, 0, indexers, byFunctionLabel)
+	c.configMapInformer = corev1informers.NewFilteredConfigMapInformer(c.clientset, corev1.NamespaceAll, 0, indexers, byFunctionLabel)
+	c.hpaInformer = autoscalingv2beta1informers.NewFilteredHorizontalPodAutoscalerInformer(c.clientset, corev1.NamespaceAll, 0, indexers, byFunctionLabel)
+
+	for _, informer := range c.gcInformers() {
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.enqueueOwnerIfOrphaned,
+			UpdateFunc: func(old, new interface{}) { c.enqueueOwnerIfOrphaned(new) },
+			DeleteFunc: c.enqueueOwnerIfOrphanedTombstone,
+		})
+	}
+}
+
+func (c *Controller) gcInformers() []cache.SharedIndexInformer {
+	return []cache.SharedIndexInformer{
+		c.serviceInformer,
+		c.deploymentInformer,
+		c.configMapInformer,
+		c.hpaInformer,
+	}
+}
+
+func (c *Controller) gcInformersSynced() []cache.InformerSynced {
+	synced := make([]cache.InformerSynced, 0, len(c.gcInformers()))
+	for _, informer := range c.gcInformers() {
+		synced = append(synced, informer.HasSynced)
+	}
+	return synced
+}
+
+// enqueueOwnerIfOrphanedTombstone is the DeleteFunc handler for every owned-
+// object informer. A missed watch event or relist delivers a
+// cache.DeletedFinalStateUnknown tombstone instead of the object itself;
+// unwrap it the same way the Function and Trigger informers already do
+// (controller.go, trigger_controller.go) so this delete isn't silently
+// dropped.
+func (c *Controller) enqueueOwnerIfOrphanedTombstone(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	c.enqueueOwnerIfOrphaned(obj)
+}
+
+// enqueueOwnerIfOrphaned is the shared Add/Update/Delete handler for every
+// owned-object informer: if the owning Function is gone, enqueue its key so
+// processItem's deleteK8sResources path runs and GCs the rest of its peers.
+func (c *Controller) enqueueOwnerIfOrphaned(obj interface{}) {
+	if !c.isLeading() {
+		return
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	refs := o.GetOwnerReferences()
+	if len(refs) == 0 || refs[0].Kind != funcKind || refs[0].APIVersion != funcAPI {
+		return
+	}
+	key := fmt.Sprintf("%s/%s", o.GetNamespace(), refs[0].Name)
+	if _, exists, err := c.informer.GetIndexer().GetByKey(key); err == nil && !exists {
+		c.queue.Add(key)
+	}
+}
+
+// runGCSafetyNet periodically re-derives orphan keys from the owner-UID
+// indexers the informers above already keep warm. Unlike the old
+// startup-only garbageCollect, this never issues a fresh List against any
+// of Services/Deployments/ConfigMaps/HPAs. CronJobs are swept separately by
+// TriggerController.runGCSafetyNet, since chunk0-1 moved their ownership to
+// the Trigger.
+func (c *Controller) runGCSafetyNet() {
+	for _, informer := range c.gcInformers() {
+		for _, obj := range informer.GetIndexer().List() {
+			c.enqueueOwnerIfOrphaned(obj)
+		}
+	}
+	if err := c.collectServiceMonitors(); err != nil {
+		c.logger.Errorf("Error collecting orphaned ServiceMonitors: %v", err)
+	}
+}
+
+// collectServiceMonitors stays List-based: ServiceMonitors are a CRD the
+// prometheus-operator owns and the controller only ever creates one per
+// function when its HPA targets an Object metric, so a filtered informer
+// isn't worth the extra watch for how rarely this needs to run.
+func (c *Controller) collectServiceMonitors() error {
+	if c.smclient == nil {
+		return nil
+	}
+	monitors, err := (*c.smclient).ServiceMonitors(corev1.NamespaceAll).List(metav1.ListOptions{LabelSelector: functionLabel})
+	if err != nil {
+		return err
+	}
+	for _, m := range monitors.Items {
+		if len(m.OwnerReferences) == 0 || m.OwnerReferences[0].Kind != funcKind || m.OwnerReferences[0].APIVersion != funcAPI {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s", m.Namespace, m.OwnerReferences[0].Name)
+		if _, exists, err := c.informer.GetIndexer().GetByKey(key); err == nil && !exists {
+			c.queue.Add(key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/util/workqueue"
+
+	kubelessApi "github.com/kubeless/kubeless/pkg/apis/kubeless/v1beta1"
+	"github.com/kubeless/kubeless/pkg/client/clientset/versioned"
+	kv1beta1 "github.com/kubeless/kubeless/pkg/client/informers/externalversions/kubeless/v1beta1"
+	"github.com/kubeless/kubeless/pkg/triggers"
+	"github.com/kubeless/kubeless/pkg/utils"
+)
+
+const (
+	triggerMaxRetries = 5
+
+	triggerLeaseDuration = 15 * time.Second
+	triggerRenewDeadline = 10 * time.Second
+	triggerRetryPeriod   = 2 * time.Second
+
+	// triggerGCSafetyNetInterval is how often runGCSafetyNet re-sweeps for
+	// TriggerLabel-ed CronJobs left behind by a missed Trigger delete event.
+	// Only CronJobs are listed; other TriggerLabel-ed objects are found only
+	// when their trigger also left a CronJob behind.
+	triggerGCSafetyNetInterval = 5 * time.Minute
+)
+
+// TriggerController watches Triggers and dispatches each one to the
+// TriggerSource registered for its Spec.Type, so adding a new event source
+// (see pkg/triggers) never requires editing this file.
+type TriggerController struct {
+	logger             *logrus.Entry
+	clientset          kubernetes.Interface
+	kubelessclient     versioned.Interface
+	queue              workqueue.RateLimitingInterface
+	informer           cache.SharedIndexInformer
+	leaseLockName      string
+	leaseLockNamespace string
+	identity           string
+	leading            int32 // accessed atomically; only the elected leader drains the queue
+}
+
+// TriggerConfig contains the k8s clients used by the TriggerController
+type TriggerConfig struct {
+	KubeCli        kubernetes.Interface
+	FunctionClient versioned.Interface
+	Kafka          triggers.KafkaConfig
+
+	// LeaseLockName/LeaseLockNamespace identify the lock object used for
+	// leader election. LeaseLockName defaults to a name distinct from the
+	// Function Controller's own lock, so the two can be run as independent
+	// replica sets. LeaseLockNamespace defaults to the controller's own
+	// namespace (the same namespace resolved via utils.GetConfigLocation).
+	LeaseLockName      string
+	LeaseLockNamespace string
+}
+
+// isLeading reports whether this replica currently holds the trigger
+// controller lease. Only the leader's worker drains the queue - running N
+// replicas without this would race on the Ingresses/Services/CronJobs/
+// Deployments the workers mutate, exactly like the Function Controller.
+func (c *TriggerController) isLeading() bool {
+	return atomic.LoadInt32(&c.leading) == 1
+}
+
+// NewTriggerController initializes a TriggerController object and
+// registers the built-in TriggerSources (http, scheduled, pubsub-kafka).
+func NewTriggerController(cfg TriggerConfig) *TriggerController {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+
+	informer := kv1beta1.NewTriggerInformer(cfg.FunctionClient, corev1.NamespaceAll, 0, cache.Indexers{})
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
+		UpdateFunc: func(old, new interface{}) {
+			key, err := cache.MetaNamespaceKeyFunc(new)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err == nil {
+				queue.Add(key)
+			}
+		},
+	})
+
+	triggers.RegisterBuiltins(cfg.KubeCli, cfg.Kafka)
+
+	configLocation := utils.GetConfigLocation()
+
+	leaseLockNamespace := cfg.LeaseLockNamespace
+	if leaseLockNamespace == "" {
+		leaseLockNamespace = configLocation["namespace"]
+	}
+	leaseLockName := cfg.LeaseLockName
+	if leaseLockName == "" {
+		leaseLockName = "kubeless-trigger-controller"
+	}
+
+	identity, err := os.Hostname()
+	if err != nil {
+		identity = "kubeless-trigger-controller-unknown"
+	}
+
+	return &TriggerController{
+		logger:             logrus.WithField("pkg", "trigger-controller"),
+		clientset:          cfg.KubeCli,
+		kubelessclient:     cfg.FunctionClient,
+		informer:           informer,
+		queue:              queue,
+		leaseLockName:      leaseLockName,
+		leaseLockNamespace: leaseLockNamespace,
+		identity:           identity,
+	}
+}
+
+// Run starts the trigger controller. The Trigger informer is kept warm on
+// every replica, but only the elected leader drains the workqueue - running
+// more than one replica without this would race on the Ingresses/Services/
+// CronJobs/Deployments the workers mutate, just like the Function
+// Controller in controller.go.
+func (c *TriggerController) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	c.logger.Info("Starting kubeless trigger controller")
+
+	go c.informer.Run(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, c.HasSynced) {
+		utilruntime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
+		return
+	}
+
+	c.logger.Info("Kubeless trigger controller synced and ready")
+
+	lock := &resourcelock.ConfigMapLock{
+		ConfigMapMeta: metav1.ObjectMeta{
+			Name:      c.leaseLockName,
+			Namespace: c.leaseLockNamespace,
+		},
+		Client:     c.clientset.CoreV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: c.identity},
+	}
+
+	leaderelection.RunOrDie(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: triggerLeaseDuration,
+		RenewDeadline: triggerRenewDeadline,
+		RetryPeriod:   triggerRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				atomic.StoreInt32(&c.leading, 1)
+				c.logger.Infof("%s became leader, starting trigger worker", c.identity)
+
+				// Sweep once right away to catch anything orphaned while no
+				// replica was leading, then keep sweeping as a safety net
+				// for delete events a replica might miss.
+				c.runGCSafetyNet()
+				go wait.Until(c.runGCSafetyNet, triggerGCSafetyNetInterval, stop)
+
+				wait.Until(c.runWorker, time.Second, stop)
+			},
+			OnStoppedLeading: func() {
+				atomic.StoreInt32(&c.leading, 0)
+				c.logger.Infof("%s is no longer the leader", c.identity)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != c.identity {
+					c.logger.Infof("New leader elected: %s", identity)
+				}
+			},
+		},
+	})
+}
+
+// HasSynced is required for the cache.Controller interface.
+func (c *TriggerController) HasSynced() bool {
+	return c.informer.HasSynced()
+}
+
+// LastSyncResourceVersion is required for the cache.Controller interface.
+func (c *TriggerController) LastSyncResourceVersion() string {
+	return c.informer.LastSyncResourceVersion()
+}
+
+func (c *TriggerController) runWorker() {
+	for c.processNextItem() {
+		// continue looping
+	}
+}
+
+func (c *TriggerController) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	err := c.processItem(key.(string))
+	if err == nil {
+		c.queue.Forget(key)
+	} else if c.queue.NumRequeues(key) < triggerMaxRetries {
+		c.logger.Errorf("Error processing trigger %s (will retry): %v", key, err)
+		c.queue.AddRateLimited(key)
+	} else {
+		c.logger.Errorf("Error processing trigger %s (giving up): %v", key, err)
+		c.queue.Forget(key)
+		utilruntime.HandleError(err)
+	}
+
+	return true
+}
+
+func (c *TriggerController) processItem(key string) error {
+	c.logger.Infof("Processing change to Trigger %s", key)
+
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	obj, exists, err := c.informer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return fmt.Errorf("Error fetching object with key %s from store: %v", key, err)
+	}
+
+	if !exists {
+		if err := c.deleteTriggerResources(ns, name); err != nil {
+			c.logger.Errorf("Can't delete trigger: %v", err)
+			return err
+		}
+		c.logger.Infof("Deleted Trigger %s", key)
+		return nil
+	}
+
+	trigger := obj.(*kubelessApi.Trigger)
+
+	source, ok := triggers.Get(trigger.Spec.Type)
+	if !ok {
+		err := triggers.ErrUnknownType(trigger.Spec.Type)
+		c.logger.Errorf("Trigger can not be reconciled: %v", err)
+		return err
+	}
+
+	condType := kubelessApi.TriggerReady
+	if source.Name() == triggers.ScheduledSourceName {
+		condType = kubelessApi.CronJobScheduled
+	}
+
+	err = source.Reconcile(context.Background(), trigger, &functionLister{c.kubelessclient})
+	if err != nil {
+		c.updateTriggerStatus(trigger, condType, kubelessApi.ConditionFalse, "ReconcileFailed", err.Error())
+		c.logger.Errorf("Trigger can not be reconciled: %v", err)
+		return err
+	}
+	c.updateTriggerStatus(trigger, condType, kubelessApi.ConditionTrue, "ReconcileSucceeded", "")
+
+	c.logger.Infof("Updated Trigger %s", key)
+	return nil
+}
+
+// updateTriggerStatus records a condition on the trigger's status
+// subresource so spec and status writes never conflict.
+func (c *TriggerController) updateTriggerStatus(trigger *kubelessApi.Trigger, condType kubelessApi.ConditionType, status kubelessApi.ConditionStatus, reason, message string) {
+	condition := kubelessApi.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+	trigger.Status.Conditions = kubelessApi.UpsertCondition(trigger.Status.Conditions, condition)
+	trigger.Status.ObservedGeneration = trigger.ObjectMeta.Generation
+
+	if _, err := c.kubelessclient.KubelessV1beta1().Triggers(trigger.ObjectMeta.Namespace).UpdateStatus(trigger); err != nil {
+		c.logger.Errorf("Unable to update status of trigger %s: %v", trigger.ObjectMeta.Name, err)
+	}
+}
+
+// deleteTriggerResources GCs everything the trigger owns. The Trigger
+// object itself (and so its Spec.Type) is already gone from the informer
+// cache by the time a delete is observed, so Cleanup is attempted against
+// every registered TriggerSource; each one is a no-op if it never created
+// anything for this name.
+func (c *TriggerController) deleteTriggerResources(ns, name string) error {
+	for _, source := range triggers.All() {
+		if err := source.Cleanup(context.Background(), ns, name); err != nil {
+			return fmt.Errorf("%s: %v", source.Name(), err)
+		}
+	}
+	return nil
+}
+
+// runGCSafetyNet re-derives orphaned trigger keys from the CronJobs every
+// TriggerSource labels with triggers.TriggerLabel, since chunk0-1 moved
+// CronJob ownership from the Function to the Trigger: the Function
+// Controller's own GC (pkg/controller/gc.go) no longer sees them, and a
+// missed Trigger delete event would otherwise leak them forever.
+func (c *TriggerController) runGCSafetyNet() {
+	cronJobs, err := c.clientset.BatchV2alpha1().CronJobs(corev1.NamespaceAll).List(metav1.ListOptions{LabelSelector: triggers.TriggerLabel})
+	if err != nil {
+		c.logger.Errorf("Error listing CronJobs for the trigger GC safety net: %v", err)
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, cronJob := range cronJobs.Items {
+		triggerName, ok := cronJob.Labels[triggers.TriggerLabel]
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s", cronJob.Namespace, triggerName)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if _, exists, err := c.informer.GetIndexer().GetByKey(key); err == nil && !exists {
+			if err := c.deleteTriggerResources(cronJob.Namespace, triggerName); err != nil {
+				c.logger.Errorf("Error GCing orphaned resources for trigger %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// functionLister resolves a Trigger's FunctionSelector against the
+// kubeless API, satisfying triggers.FunctionLister.
+type functionLister struct {
+	kubelessclient versioned.Interface
+}
+
+func (l *functionLister) List(namespace string, trigger *kubelessApi.Trigger) ([]*kubelessApi.Function, error) {
+	selector, err := metav1.LabelSelectorAsSelector(trigger.Spec.FunctionSelector)
+	if err != nil {
+		return nil, err
+	}
+	list, err := l.kubelessclient.KubelessV1beta1().Functions(namespace).List(metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	functions := make([]*kubelessApi.Function, 0, len(list.Items))
+	for i := range list.Items {
+		functions = append(functions, &list.Items[i])
+	}
+	return functions, nil
+}
@@ -0,0 +1,27 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package triggers
+
+import (
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// isNotFound is shared by every source's Cleanup: deleting an object that's
+// already gone is not an error, since Cleanup must be idempotent.
+func isNotFound(err error) bool {
+	return k8sErrors.IsNotFound(err)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package triggers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	kubelessApi "github.com/kubeless/kubeless/pkg/apis/kubeless/v1beta1"
+	"github.com/kubeless/kubeless/pkg/utils"
+)
+
+// HTTPSourceName is the registry key used for the built-in HTTP backend.
+const HTTPSourceName = "HTTP"
+
+type httpSource struct {
+	clientset kubernetes.Interface
+}
+
+// NewHTTPSource builds the TriggerSource backing HTTP triggers: a Service
+// built from Spec.ServiceSpec and an Ingress pointed at it, one pair per
+// matched Function. The Service is owned by the Trigger, not the Function -
+// it must not be the same object the Function controller's own
+// ensureK8sResources already owns, or the two would fight over its
+// OwnerReferences.
+func NewHTTPSource(clientset kubernetes.Interface) TriggerSource {
+	return &httpSource{clientset: clientset}
+}
+
+func (s *httpSource) Name() string {
+	return HTTPSourceName
+}
+
+func (s *httpSource) Validate(spec *kubelessApi.TriggerSpec) error {
+	return nil
+}
+
+func (s *httpSource) Reconcile(ctx context.Context, trigger *kubelessApi.Trigger, functions FunctionLister) error {
+	fns, err := functions.List(trigger.ObjectMeta.Namespace, trigger)
+	if err != nil {
+		return err
+	}
+	or, err := utils.GetOwnerReference(trigger)
+	if err != nil {
+		return err
+	}
+	for _, fn := range fns {
+		if err := s.ensureService(trigger, fn, or); err != nil {
+			return err
+		}
+		if err := utils.EnsureFuncIngress(s.clientset, trigger, fn, or); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func serviceName(triggerName, functionName string) string {
+	return fmt.Sprintf("trigger-%s-%s", triggerName, functionName)
+}
+
+// ensureService creates/updates the per-(trigger, function) Service backing
+// an HTTP trigger, built from trigger.Spec.ServiceSpec. It is a distinct
+// object from the Function's own Service (owned by the Function controller),
+// pointed at the same Function pods, so a Trigger can be deleted without
+// cascading into the Function's Service via native k8s GC.
+func (s *httpSource) ensureService(trigger *kubelessApi.Trigger, fn *kubelessApi.Function, or []metav1.OwnerReference) error {
+	name := serviceName(trigger.ObjectMeta.Name, fn.ObjectMeta.Name)
+	labels := map[string]string{
+		"kubeless.io/function": fn.ObjectMeta.Name,
+		TriggerLabel:           trigger.ObjectMeta.Name,
+	}
+
+	spec := trigger.Spec.ServiceSpec
+	if len(spec.Selector) == 0 {
+		spec.Selector = map[string]string{"function": fn.ObjectMeta.Name}
+	}
+
+	namespace := trigger.ObjectMeta.Namespace
+	existing, err := s.clientset.Core().Services(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if !isNotFound(err) {
+			return err
+		}
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       namespace,
+				Labels:          labels,
+				OwnerReferences: or,
+			},
+			Spec: spec,
+		}
+		_, err := s.clientset.Core().Services(namespace).Create(svc)
+		return err
+	}
+
+	// ClusterIP is immutable once assigned; preserve it across updates.
+	spec.ClusterIP = existing.Spec.ClusterIP
+	existing.Labels = labels
+	existing.Spec = spec
+	_, err = s.clientset.Core().Services(namespace).Update(existing)
+	return err
+}
+
+// Cleanup lists every Service and Ingress this trigger owns (one pair per
+// matched Function) by TriggerLabel and deletes them all, rather than
+// guessing a single name - the Trigger object itself is already gone from
+// the cache by the time this runs, so a FunctionSelector fan-out can only
+// be discovered via a list.
+func (s *httpSource) Cleanup(ctx context.Context, namespace, name string) error {
+	selector := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", TriggerLabel, name)}
+
+	services, err := s.clientset.Core().Services(namespace).List(selector)
+	if err != nil {
+		return err
+	}
+	for _, svc := range services.Items {
+		if err := s.clientset.Core().Services(namespace).Delete(svc.Name, &metav1.DeleteOptions{}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("Unable to delete Service %s for trigger %s/%s: %v", svc.Name, namespace, name, err)
+		}
+	}
+
+	ingresses, err := s.clientset.Extensions().Ingresses(namespace).List(selector)
+	if err != nil {
+		return err
+	}
+	for _, ing := range ingresses.Items {
+		if err := s.clientset.Extensions().Ingresses(namespace).Delete(ing.Name, &metav1.DeleteOptions{}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("Unable to delete Ingress %s for trigger %s/%s: %v", ing.Name, namespace, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package triggers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	kubelessApi "github.com/kubeless/kubeless/pkg/apis/kubeless/v1beta1"
+	"github.com/kubeless/kubeless/pkg/utils"
+)
+
+// PubSubKafkaSourceName is the registry key used for the built-in Kafka backend.
+const PubSubKafkaSourceName = "PubSub"
+
+type pubsubKafkaSource struct {
+	clientset   kubernetes.Interface
+	kafkaImage  string
+	brokerHosts string
+}
+
+// NewPubSubKafkaSource builds the TriggerSource backing PubSub triggers: a
+// consumer Deployment per matched Function, subscribed to Spec.Topic, that
+// forwards every message as an HTTP POST to the function's Service.
+func NewPubSubKafkaSource(clientset kubernetes.Interface, kafkaImage, brokerHosts string) TriggerSource {
+	if kafkaImage == "" {
+		kafkaImage = "bitnami/kubeless-kafka-trigger:latest"
+	}
+	return &pubsubKafkaSource{clientset: clientset, kafkaImage: kafkaImage, brokerHosts: brokerHosts}
+}
+
+func (s *pubsubKafkaSource) Name() string {
+	return PubSubKafkaSourceName
+}
+
+func (s *pubsubKafkaSource) Validate(spec *kubelessApi.TriggerSpec) error {
+	if spec.Topic == "" {
+		return fmt.Errorf("PubSub trigger has no topic set")
+	}
+	return nil
+}
+
+func (s *pubsubKafkaSource) Reconcile(ctx context.Context, trigger *kubelessApi.Trigger, functions FunctionLister) error {
+	fns, err := functions.List(trigger.ObjectMeta.Namespace, trigger)
+	if err != nil {
+		return err
+	}
+	or, err := utils.GetOwnerReference(trigger)
+	if err != nil {
+		return err
+	}
+	for _, fn := range fns {
+		deployment := s.consumerDeployment(trigger, fn, or)
+		existing, err := s.clientset.Extensions().Deployments(trigger.ObjectMeta.Namespace).Get(deployment.Name, metav1.GetOptions{})
+		if err != nil {
+			if !isNotFound(err) {
+				return err
+			}
+			if _, err := s.clientset.Extensions().Deployments(trigger.ObjectMeta.Namespace).Create(deployment); err != nil {
+				return err
+			}
+			continue
+		}
+		existing.Spec = deployment.Spec
+		if _, err := s.clientset.Extensions().Deployments(trigger.ObjectMeta.Namespace).Update(existing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cleanup lists every consumer Deployment this trigger owns (one per
+// matched Function) by TriggerLabel and deletes them all, rather than
+// guessing a single name - the Trigger object itself is already gone from
+// the cache by the time this runs, so a FunctionSelector fan-out can only
+// be discovered via a list.
+func (s *pubsubKafkaSource) Cleanup(ctx context.Context, namespace, name string) error {
+	deployments, err := s.clientset.Extensions().Deployments(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", TriggerLabel, name),
+	})
+	if err != nil {
+		return err
+	}
+	deletePolicy := metav1.DeletePropagationBackground
+	for _, d := range deployments.Items {
+		if err := s.clientset.Extensions().Deployments(namespace).Delete(d.Name, &metav1.DeleteOptions{PropagationPolicy: &deletePolicy}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("Unable to delete Kafka consumer %s for trigger %s/%s: %v", d.Name, namespace, name, err)
+		}
+	}
+	return nil
+}
+
+// consumerName is keyed by both trigger and function: a FunctionSelector
+// can fan one trigger out to several functions, and each needs its own
+// consumer Deployment rather than all of them racing to own one name.
+func consumerName(triggerName, functionName string) string {
+	return fmt.Sprintf("kafka-trigger-%s-%s", triggerName, functionName)
+}
+
+// consumerDeployment builds the Deployment running the Kafka consumer for
+// one (trigger, function) pair. The consumer subscribes to Spec.Topic and
+// POSTs each message's payload to the function's in-cluster Service.
+func (s *pubsubKafkaSource) consumerDeployment(trigger *kubelessApi.Trigger, fn *kubelessApi.Function, or []metav1.OwnerReference) *v1beta1.Deployment {
+	name := consumerName(trigger.ObjectMeta.Name, fn.ObjectMeta.Name)
+	labels := map[string]string{
+		"kubeless.io/function": fn.ObjectMeta.Name,
+		TriggerLabel:           trigger.ObjectMeta.Name,
+	}
+	functionURL := fmt.Sprintf("http://%s.%s.svc.cluster.local:8080", fn.ObjectMeta.Name, fn.ObjectMeta.Namespace)
+
+	return &v1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       trigger.ObjectMeta.Namespace,
+			Labels:          labels,
+			OwnerReferences: or,
+		},
+		Spec: v1beta1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "kafka-trigger",
+							Image: s.kafkaImage,
+							Env: []corev1.EnvVar{
+								{Name: "KUBELESS_TOPIC", Value: trigger.Spec.Topic},
+								{Name: "KUBELESS_BROKERS", Value: s.brokerHosts},
+								{Name: "KUBELESS_FUNCTION_URL", Value: functionURL},
+							},
+							Ports: []corev1.ContainerPort{{ContainerPort: 8080}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
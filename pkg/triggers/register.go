@@ -0,0 +1,38 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package triggers
+
+import (
+	"k8s.io/client-go/kubernetes"
+)
+
+// KafkaConfig configures the built-in PubSub (Kafka) source. The zero value
+// is valid and falls back to the default consumer image with no brokers
+// pre-set, relying on KUBELESS_BROKERS being provided some other way.
+type KafkaConfig struct {
+	Image       string
+	BrokerHosts string
+}
+
+// RegisterBuiltins registers the http, scheduled and pubsub-kafka
+// TriggerSources this package ships. Called once at controller startup;
+// third parties add more sources the same way, by calling Register.
+func RegisterBuiltins(clientset kubernetes.Interface, kafka KafkaConfig) {
+	Register(HTTPSourceName, NewHTTPSource(clientset))
+	Register(ScheduledSourceName, NewScheduledSource(clientset))
+	Register(PubSubKafkaSourceName, NewPubSubKafkaSource(clientset, kafka.Image, kafka.BrokerHosts))
+}
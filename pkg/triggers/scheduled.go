@@ -0,0 +1,114 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package triggers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robfig/cron"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	kubelessApi "github.com/kubeless/kubeless/pkg/apis/kubeless/v1beta1"
+	"github.com/kubeless/kubeless/pkg/utils"
+)
+
+// ScheduledSourceName is the registry key used for the built-in Scheduled backend.
+const ScheduledSourceName = "Scheduled"
+
+type scheduledSource struct {
+	clientset kubernetes.Interface
+}
+
+// NewScheduledSource builds the TriggerSource backing Scheduled triggers:
+// a CronJob per matched Function, running on Spec.Schedule. This is the
+// logic that used to live inline in Controller.ensureK8sResources.
+func NewScheduledSource(clientset kubernetes.Interface) TriggerSource {
+	return &scheduledSource{clientset: clientset}
+}
+
+func (s *scheduledSource) Name() string {
+	return ScheduledSourceName
+}
+
+func (s *scheduledSource) Validate(spec *kubelessApi.TriggerSpec) error {
+	if spec.Schedule == "" {
+		return fmt.Errorf("Scheduled trigger has no schedule set")
+	}
+	if _, err := cron.Parse(spec.Schedule); err != nil {
+		return fmt.Errorf("Invalid cron schedule %q: %v", spec.Schedule, err)
+	}
+	return nil
+}
+
+func (s *scheduledSource) Reconcile(ctx context.Context, trigger *kubelessApi.Trigger, functions FunctionLister) error {
+	fns, err := functions.List(trigger.ObjectMeta.Namespace, trigger)
+	if err != nil {
+		return err
+	}
+	or, err := utils.GetOwnerReference(trigger)
+	if err != nil {
+		return err
+	}
+	groupVersion, err := s.resourceGroupVersion("cronjobs")
+	if err != nil {
+		return err
+	}
+	restIface := s.clientset.BatchV2alpha1().RESTClient()
+	for _, fn := range fns {
+		if err := utils.EnsureTriggerCronJob(restIface, trigger, fn, or, groupVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cleanup lists every CronJob this trigger owns (one per matched Function)
+// by TriggerLabel and deletes them all, rather than guessing a single name -
+// the Trigger object itself is already gone from the cache by the time this
+// runs, so a FunctionSelector fan-out can only be discovered via a list.
+func (s *scheduledSource) Cleanup(ctx context.Context, namespace, name string) error {
+	cronJobs, err := s.clientset.BatchV2alpha1().CronJobs(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", TriggerLabel, name),
+	})
+	if err != nil {
+		return err
+	}
+	deletePolicy := metav1.DeletePropagationBackground
+	for _, cj := range cronJobs.Items {
+		if err := s.clientset.BatchV2alpha1().CronJobs(namespace).Delete(cj.Name, &metav1.DeleteOptions{PropagationPolicy: &deletePolicy}); err != nil && !isNotFound(err) {
+			return fmt.Errorf("Unable to delete CronJob %s for trigger %s/%s: %v", cj.Name, namespace, name, err)
+		}
+	}
+	return nil
+}
+
+func (s *scheduledSource) resourceGroupVersion(target string) (string, error) {
+	resources, err := s.clientset.Discovery().ServerResources()
+	if err != nil {
+		return "", err
+	}
+	for _, resource := range resources {
+		for _, apiResource := range resource.APIResources {
+			if apiResource.Name == target {
+				return resource.GroupVersion, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("Resource %s not found in any group", target)
+}
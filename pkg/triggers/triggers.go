@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package triggers decouples TriggerController from any one event-source
+// implementation. Extending kubeless to a new event source (Kafka, NATS,
+// a raw HTTP endpoint, ...) means adding a TriggerSource and registering
+// it, rather than editing the controller itself.
+package triggers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	kubelessApi "github.com/kubeless/kubeless/pkg/apis/kubeless/v1beta1"
+)
+
+// FunctionLister resolves the Functions a Trigger fans out to via its
+// FunctionSelector, so a TriggerSource never has to know how Functions are
+// stored or cached.
+type FunctionLister interface {
+	List(namespace string, trigger *kubelessApi.Trigger) ([]*kubelessApi.Function, error)
+}
+
+// TriggerSource is implemented by each pluggable event-source backend. All
+// methods must be safe to call concurrently and idempotent: Reconcile and
+// Cleanup both run from a rate-limited workqueue and may be retried.
+type TriggerSource interface {
+	// Name identifies the backend, e.g. for logging.
+	Name() string
+	// Validate rejects a TriggerSpec this backend cannot serve, before
+	// Reconcile ever runs against it.
+	Validate(spec *kubelessApi.TriggerSpec) error
+	// Reconcile creates/updates whatever objects back this trigger for
+	// every Function the trigger's selector resolves to.
+	Reconcile(ctx context.Context, trigger *kubelessApi.Trigger, functions FunctionLister) error
+	// Cleanup removes the objects this backend owns for the given
+	// Trigger. Called with just the namespaced name, since by the time a
+	// delete event is observed the Trigger object itself is already gone
+	// from the informer cache.
+	Cleanup(ctx context.Context, namespace, name string) error
+}
+
+// TriggerLabel is set on every object a TriggerSource creates on behalf of
+// a Trigger (Ingress, CronJob, Deployment, ...), so Cleanup can list every
+// object a trigger owns instead of guessing a single hardcoded name - a
+// FunctionSelector can fan one trigger out to N per-function objects.
+const TriggerLabel = "kubeless.io/trigger"
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]TriggerSource{}
+)
+
+// Register adds a TriggerSource under TriggerSpec.Type. RegisterBuiltins
+// calls this for the sources shipped in this package; third parties can
+// call it themselves at startup to add a source without touching
+// TriggerController.
+func Register(triggerType string, source TriggerSource) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[triggerType] = source
+}
+
+// Get looks up the TriggerSource registered for a TriggerSpec.Type.
+func Get(triggerType string) (TriggerSource, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	source, ok := registry[triggerType]
+	return source, ok
+}
+
+// All returns every registered TriggerSource, in no particular order.
+// TriggerController uses this to attempt Cleanup across every backend when
+// a Trigger is deleted, since its Type is no longer available by then.
+func All() []TriggerSource {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	sources := make([]TriggerSource, 0, len(registry))
+	for _, source := range registry {
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// ErrUnknownType is returned by TriggerController when a Trigger's Type has
+// no registered TriggerSource.
+func ErrUnknownType(triggerType string) error {
+	return fmt.Errorf("No TriggerSource registered for type %q", triggerType)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package triggers
+
+import (
+	"context"
+	"testing"
+
+	kubelessApi "github.com/kubeless/kubeless/pkg/apis/kubeless/v1beta1"
+)
+
+type stubSource struct{ name string }
+
+func (s *stubSource) Name() string { return s.name }
+func (s *stubSource) Validate(spec *kubelessApi.TriggerSpec) error { return nil }
+func (s *stubSource) Reconcile(ctx context.Context, trigger *kubelessApi.Trigger, functions FunctionLister) error {
+	return nil
+}
+func (s *stubSource) Cleanup(ctx context.Context, namespace, name string) error { return nil }
+
+func TestRegisterAndGet(t *testing.T) {
+	registryMu.Lock()
+	registry = map[string]TriggerSource{}
+	registryMu.Unlock()
+
+	source := &stubSource{name: "Stub"}
+	Register("Stub", source)
+
+	got, ok := Get("Stub")
+	if !ok {
+		t.Fatal("expected Get to find the registered source")
+	}
+	if got.Name() != "Stub" {
+		t.Errorf("expected source named Stub, got %q", got.Name())
+	}
+
+	if _, ok := Get("NoSuchType"); ok {
+		t.Error("expected Get to report no source for an unregistered type")
+	}
+}
+
+func TestAllReturnsEveryRegisteredSource(t *testing.T) {
+	registryMu.Lock()
+	registry = map[string]TriggerSource{}
+	registryMu.Unlock()
+
+	Register("A", &stubSource{name: "A"})
+	Register("B", &stubSource{name: "B"})
+
+	all := All()
+	if len(all) != 2 {
+		t.Fatalf("expected 2 sources, got %d", len(all))
+	}
+}
+
+func TestErrUnknownType(t *testing.T) {
+	err := ErrUnknownType("Bogus")
+	if err == nil {
+		t.Fatal("expected a non-nil error")
+	}
+}
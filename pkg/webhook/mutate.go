@@ -0,0 +1,75 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/api/admission/v1beta1"
+
+	kubelessApi "github.com/kubeless/kubeless/pkg/apis/kubeless/v1beta1"
+)
+
+type jsonPatch struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// mutate defaults the runtime image tag and the "function"/"created-by"
+// labels on a Function before it reaches the controller, so callers don't
+// have to fill those in by hand. The runtime image is only defaulted when
+// the Function doesn't already pin its own, so this never clobbers a
+// user-supplied image.
+func (s *Server) mutate(review *v1beta1.AdmissionReview) *v1beta1.AdmissionResponse {
+	req := review.Request
+	if req.Resource.Resource != "functions" {
+		return allow()
+	}
+
+	fn := &kubelessApi.Function{}
+	if err := json.Unmarshal(req.Object.Raw, fn); err != nil {
+		return deny(fmt.Sprintf("Unable to decode Function: %v", err))
+	}
+
+	var patches []jsonPatch
+
+	if len(fn.ObjectMeta.Labels) == 0 {
+		patches = append(patches, jsonPatch{Op: "add", Path: "/metadata/labels", Value: map[string]string{}})
+	}
+	patches = append(patches, jsonPatch{Op: "add", Path: "/metadata/labels/function", Value: fn.ObjectMeta.Name})
+	patches = append(patches, jsonPatch{Op: "add", Path: "/metadata/labels/created-by", Value: "kubeless"})
+
+	if fn.Spec.Runtime != "" && fn.Spec.RuntimeImage == "" && s.cfg.LangRuntime != nil {
+		if image := s.cfg.LangRuntime.DefaultImage(fn.Spec.Runtime); image != "" {
+			patches = append(patches, jsonPatch{Op: "add", Path: "/spec/runtime-image", Value: image})
+		}
+	}
+
+	patchBytes, err := json.Marshal(patches)
+	if err != nil {
+		return deny(fmt.Sprintf("Unable to build patch: %v", err))
+	}
+
+	patchType := v1beta1.PatchTypeJSONPatch
+	return &v1beta1.AdmissionResponse{
+		Allowed:   true,
+		Patch:     patchBytes,
+		PatchType: &patchType,
+	}
+}
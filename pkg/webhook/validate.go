@@ -0,0 +1,127 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/api/autoscaling/v2beta1"
+
+	kubelessApi "github.com/kubeless/kubeless/pkg/apis/kubeless/v1beta1"
+	"github.com/kubeless/kubeless/pkg/triggers"
+)
+
+// validate dispatches to the per-kind validator based on the admission
+// request's resource, reusing langruntime.Langruntimes the same way the
+// controller does so both paths agree on what a supported runtime is.
+func (s *Server) validate(review *v1beta1.AdmissionReview) *v1beta1.AdmissionResponse {
+	req := review.Request
+
+	switch req.Resource.Resource {
+	case "functions":
+		fn := &kubelessApi.Function{}
+		if err := json.Unmarshal(req.Object.Raw, fn); err != nil {
+			return deny(fmt.Sprintf("Unable to decode Function: %v", err))
+		}
+		if err := s.validateFunction(fn); err != nil {
+			return deny(err.Error())
+		}
+	case "triggers":
+		trigger := &kubelessApi.Trigger{}
+		if err := json.Unmarshal(req.Object.Raw, trigger); err != nil {
+			return deny(fmt.Sprintf("Unable to decode Trigger: %v", err))
+		}
+		if err := validateTrigger(trigger); err != nil {
+			return deny(err.Error())
+		}
+	case "horizontalpodautoscalers":
+		hpa := &v2beta1.HorizontalPodAutoscaler{}
+		if err := json.Unmarshal(req.Object.Raw, hpa); err != nil {
+			return deny(fmt.Sprintf("Unable to decode HorizontalPodAutoscaler: %v", err))
+		}
+		if err := validateHPA(hpa); err != nil {
+			return deny(err.Error())
+		}
+	}
+
+	return allow()
+}
+
+// validateFunction rejects a Function whose Spec.Runtime isn't supported by
+// this deployment's kubeless-function-deployment-config, or whose embedded
+// Spec.HorizontalPodAutoscaler has a Metrics shape the controller can't
+// handle - ensureK8sResources indexes Metrics[0] unconditionally once an
+// HPA is set, so that must be checked here rather than discovered there.
+func (s *Server) validateFunction(fn *kubelessApi.Function) error {
+	if s.cfg.LangRuntime != nil && !s.cfg.LangRuntime.IsValidRuntime(fn.Spec.Runtime) {
+		return fmt.Errorf("Function %s/%s uses unsupported runtime %q", fn.ObjectMeta.Namespace, fn.ObjectMeta.Name, fn.Spec.Runtime)
+	}
+	hpa := fn.Spec.HorizontalPodAutoscaler
+	if hpa.Name != "" && hpa.Spec.ScaleTargetRef.Name != "" {
+		if err := validateHPA(&hpa); err != nil {
+			return fmt.Errorf("Function %s/%s has an invalid HorizontalPodAutoscaler: %v", fn.ObjectMeta.Namespace, fn.ObjectMeta.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateTrigger rejects TriggerSpec combinations the controller would
+// otherwise only discover deep inside the per-type reconcilers. It defers
+// entirely to the TriggerSource registered for spec.Type instead of
+// reimplementing each backend's checks here, so a third party registering
+// its own TriggerSource (see pkg/triggers) gets its Validate called too,
+// rather than being rejected by a hardcoded switch that's never heard of it.
+func validateTrigger(trigger *kubelessApi.Trigger) error {
+	spec := trigger.Spec
+	source, ok := triggers.Get(spec.Type)
+	if !ok {
+		return fmt.Errorf("Trigger %s/%s has unknown type %q", trigger.ObjectMeta.Namespace, trigger.ObjectMeta.Name, spec.Type)
+	}
+	if err := source.Validate(&spec); err != nil {
+		return fmt.Errorf("Trigger %s/%s is invalid: %v", trigger.ObjectMeta.Namespace, trigger.ObjectMeta.Name, err)
+	}
+	return nil
+}
+
+// validateHPA verifies the HorizontalPodAutoscaler.Spec.Metrics shape before
+// the controller tries to inspect Metrics[0] when deciding whether to wire
+// up a ServiceMonitor.
+func validateHPA(hpa *v2beta1.HorizontalPodAutoscaler) error {
+	if len(hpa.Spec.Metrics) == 0 {
+		return fmt.Errorf("HorizontalPodAutoscaler %s/%s has no metrics defined", hpa.ObjectMeta.Namespace, hpa.ObjectMeta.Name)
+	}
+	metric := hpa.Spec.Metrics[0]
+	switch metric.Type {
+	case v2beta1.ObjectMetricSourceType:
+		if metric.Object == nil {
+			return fmt.Errorf("HorizontalPodAutoscaler %s/%s declares an Object metric with no Object field", hpa.ObjectMeta.Namespace, hpa.ObjectMeta.Name)
+		}
+	case v2beta1.ResourceMetricSourceType:
+		if metric.Resource == nil {
+			return fmt.Errorf("HorizontalPodAutoscaler %s/%s declares a Resource metric with no Resource field", hpa.ObjectMeta.Namespace, hpa.ObjectMeta.Name)
+		}
+	case v2beta1.PodsMetricSourceType:
+		if metric.Pods == nil {
+			return fmt.Errorf("HorizontalPodAutoscaler %s/%s declares a Pods metric with no Pods field", hpa.ObjectMeta.Namespace, hpa.ObjectMeta.Name)
+		}
+	default:
+		return fmt.Errorf("HorizontalPodAutoscaler %s/%s has unknown metric type %q", hpa.ObjectMeta.Namespace, hpa.ObjectMeta.Name, metric.Type)
+	}
+	return nil
+}
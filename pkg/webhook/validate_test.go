@@ -0,0 +1,128 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kubelessApi "github.com/kubeless/kubeless/pkg/apis/kubeless/v1beta1"
+	"github.com/kubeless/kubeless/pkg/triggers"
+)
+
+var errInvalidFakeSource = errors.New("fake source rejects this spec")
+
+func TestValidateHPA(t *testing.T) {
+	meta := metav1.ObjectMeta{Namespace: "ns", Name: "hpa"}
+
+	tests := []struct {
+		name    string
+		metrics []autoscalingv2beta1.MetricSpec
+		wantErr bool
+	}{
+		{"no metrics", nil, true},
+		{
+			"object metric without Object field", []autoscalingv2beta1.MetricSpec{
+				{Type: autoscalingv2beta1.ObjectMetricSourceType},
+			}, true,
+		},
+		{
+			"valid object metric", []autoscalingv2beta1.MetricSpec{
+				{Type: autoscalingv2beta1.ObjectMetricSourceType, Object: &autoscalingv2beta1.ObjectMetricSource{}},
+			}, false,
+		},
+		{
+			"valid resource metric", []autoscalingv2beta1.MetricSpec{
+				{Type: autoscalingv2beta1.ResourceMetricSourceType, Resource: &autoscalingv2beta1.ResourceMetricSource{}},
+			}, false,
+		},
+		{
+			"unknown metric type", []autoscalingv2beta1.MetricSpec{
+				{Type: "Bogus"},
+			}, true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hpa := &autoscalingv2beta1.HorizontalPodAutoscaler{
+				ObjectMeta: meta,
+				Spec:       autoscalingv2beta1.HorizontalPodAutoscalerSpec{Metrics: tt.metrics},
+			}
+			err := validateHPA(hpa)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateHPA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTriggerDelegatesToRegisteredSource(t *testing.T) {
+	registerFakeSources(t)
+
+	trigger := &kubelessApi.Trigger{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "t"},
+		Spec:       kubelessApi.TriggerSpec{Type: "fake-valid"},
+	}
+	if err := validateTrigger(trigger); err != nil {
+		t.Errorf("expected a trigger backed by a valid source to pass, got %v", err)
+	}
+
+	trigger.Spec.Type = "fake-invalid"
+	if err := validateTrigger(trigger); err == nil {
+		t.Error("expected a trigger backed by an invalid source to be rejected")
+	}
+}
+
+func TestValidateTriggerRejectsUnknownType(t *testing.T) {
+	registerFakeSources(t)
+
+	trigger := &kubelessApi.Trigger{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "t"},
+		Spec:       kubelessApi.TriggerSpec{Type: "NoSuchType"},
+	}
+	if err := validateTrigger(trigger); err == nil {
+		t.Error("expected an unregistered trigger type to be rejected")
+	}
+}
+
+type fakeSource struct {
+	name    string
+	invalid bool
+}
+
+func (s *fakeSource) Name() string { return s.name }
+func (s *fakeSource) Validate(spec *kubelessApi.TriggerSpec) error {
+	if s.invalid {
+		return errInvalidFakeSource
+	}
+	return nil
+}
+func (s *fakeSource) Reconcile(ctx context.Context, trigger *kubelessApi.Trigger, functions triggers.FunctionLister) error {
+	return nil
+}
+func (s *fakeSource) Cleanup(ctx context.Context, namespace, name string) error { return nil }
+
+func registerFakeSources(t *testing.T) {
+	t.Helper()
+	triggers.Register("fake-valid", &fakeSource{name: "fake-valid"})
+	triggers.Register("fake-invalid", &fakeSource{name: "fake-invalid", invalid: true})
+}
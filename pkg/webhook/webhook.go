@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2016-2017 Bitnami
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook serves the ValidatingAdmissionWebhook and
+// MutatingAdmissionWebhook kubeless registers for Function and Trigger
+// objects, so bad specs are rejected before the controller ever reconciles
+// them instead of failing deep inside ensureK8sResources.
+package webhook
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+
+	"github.com/kubeless/kubeless/pkg/langruntime"
+)
+
+var (
+	scheme = runtime.NewScheme()
+	codecs = serializer.NewCodecFactory(scheme)
+	logger = logrus.WithField("pkg", "webhook")
+)
+
+func init() {
+	_ = v1beta1.AddToScheme(scheme)
+}
+
+// Config holds the TLS and runtime dependencies the webhook server needs
+type Config struct {
+	ListenAddr  string
+	CertFile    string
+	KeyFile     string
+	LangRuntime *langruntime.Langruntimes
+}
+
+// Server serves the validating and mutating admission webhooks over TLS
+type Server struct {
+	cfg Config
+}
+
+// New initializes a webhook Server
+func New(cfg Config) *Server {
+	return &Server{cfg: cfg}
+}
+
+// Run starts the HTTPS server and blocks until it exits
+func (s *Server) Run() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/validate", s.serve(s.validate))
+	mux.HandleFunc("/mutate", s.serve(s.mutate))
+
+	cert, err := tls.LoadX509KeyPair(s.cfg.CertFile, s.cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:      s.cfg.ListenAddr,
+		Handler:   mux,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+
+	logger.Infof("Webhook listening on %s", s.cfg.ListenAddr)
+	return server.ListenAndServeTLS("", "")
+}
+
+type admitFunc func(review *v1beta1.AdmissionReview) *v1beta1.AdmissionResponse
+
+func (s *Server) serve(admit admitFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		review := v1beta1.AdmissionReview{}
+		if _, _, err := codecs.UniversalDeserializer().Decode(body, nil, &review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := admit(&review)
+		review.Response = response
+		review.Response.UID = review.Request.UID
+
+		resp, err := json.Marshal(review)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	}
+}
+
+func deny(reason string) *v1beta1.AdmissionResponse {
+	return &v1beta1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Message: reason},
+	}
+}
+
+func allow() *v1beta1.AdmissionResponse {
+	return &v1beta1.AdmissionResponse{Allowed: true}
+}